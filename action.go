@@ -0,0 +1,62 @@
+package radix
+
+// Action describes an action that can be performed using a Conn.
+type Action interface {
+	// Key returns the key the Action will operate on, or nil if the Action
+	// doesn't operate on any key (e.g. PING) or operates on more than one.
+	Key() []byte
+
+	// Run actually performs the Action using the given Conn.
+	Run(c Conn) error
+}
+
+// CmdAction is an Action which knows the name of the redis command it
+// performs, and whether that command only reads data. It's implemented by
+// the Actions returned from Cmd, CmdNoKey, and LuaCmd.
+type CmdAction interface {
+	Action
+
+	// ReadOnly returns true if this command only reads data and so can
+	// safely be routed to a replica by a Cluster with ClusterOpts.ReadOnly
+	// set. It defaults to true only for a whitelist of known read-only
+	// commands (GET, MGET, HGET, HGETALL, SMEMBERS, ZRANGE, ...); any
+	// command not on that whitelist is treated as a write.
+	ReadOnly() bool
+}
+
+// readOnlyCmds is the whitelist of redis commands which are safe to route
+// to a cluster replica.
+var readOnlyCmds = map[string]bool{
+	"GET":        true,
+	"MGET":       true,
+	"GETRANGE":   true,
+	"STRLEN":     true,
+	"EXISTS":     true,
+	"TYPE":       true,
+	"TTL":        true,
+	"HGET":       true,
+	"HMGET":      true,
+	"HGETALL":    true,
+	"HKEYS":      true,
+	"HVALS":      true,
+	"HLEN":       true,
+	"SMEMBERS":   true,
+	"SISMEMBER":  true,
+	"SCARD":      true,
+	"LRANGE":     true,
+	"LLEN":       true,
+	"LINDEX":     true,
+	"ZRANGE":     true,
+	"ZREVRANGE":  true,
+	"ZSCORE":     true,
+	"ZCARD":      true,
+	"ZRANK":      true,
+}
+
+// IsReadOnlyCmd returns whether cmd (a redis command name, e.g. "GET") is in
+// the whitelist of commands known to only read data. It's used by the
+// concrete Actions returned from Cmd/CmdNoKey/LuaCmd to implement
+// CmdAction.ReadOnly.
+func IsReadOnlyCmd(cmd string) bool {
+	return readOnlyCmds[cmd]
+}
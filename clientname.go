@@ -0,0 +1,68 @@
+package radix
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var clientNameCounter int64
+
+// ResolveClientName expands the special client name "auto" into a unique,
+// process-specific name of the form "radix-<pid>-<counter>". Any other name
+// is returned unchanged.
+func ResolveClientName(name string) string {
+	if name != "auto" {
+		return name
+	}
+	n := atomic.AddInt64(&clientNameCounter, 1)
+	return "radix-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(n, 10)
+}
+
+// SetClientName issues CLIENT SETNAME on c using name, expanding "auto" via
+// ResolveClientName first.
+func SetClientName(c Conn, name string) error {
+	return CmdNoKey("CLIENT", "SETNAME", ResolveClientName(name)).Run(c)
+}
+
+// TagConnNames wraps p so that every distinct connection p ever hands out to
+// an Action is tagged with name via CLIENT SETNAME the first time it's
+// borrowed, rather than just whichever single connection a bare p.Do call
+// happens to reach. If name is "", p is returned unchanged.
+func TagConnNames(p Client, name string) Client {
+	if name == "" {
+		return p
+	}
+	return &taggedClient{Client: p, name: ResolveClientName(name), tagged: map[Conn]bool{}}
+}
+
+// taggedClient implements Client, delegating everything to the wrapped
+// Client except Do, which tags each newly-seen connection before running the
+// Action on it.
+type taggedClient struct {
+	Client
+	name string
+
+	l      sync.Mutex
+	tagged map[Conn]bool
+}
+
+func (tc *taggedClient) Do(a Action) error {
+	return tc.Client.Do(WithConn(a.Key(), func(conn Conn) error {
+		tc.l.Lock()
+		alreadyTagged := tc.tagged[conn]
+		tc.l.Unlock()
+
+		if !alreadyTagged {
+			if err := SetClientName(conn, tc.name); err != nil {
+				return err
+			}
+			tc.l.Lock()
+			tc.tagged[conn] = true
+			tc.l.Unlock()
+		}
+
+		return a.Run(conn)
+	}))
+}
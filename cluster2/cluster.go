@@ -5,8 +5,10 @@
 package cluster
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
 	"sync"
 	"time"
@@ -41,18 +43,57 @@ func (d *dedupe) do(fn func()) {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// ClusterOpts are used to configure the behavior of a Cluster returned from
+// NewClusterWithOpts. A zero-value ClusterOpts gives the same behavior as
+// NewCluster.
+type ClusterOpts struct {
+	// ReadOnly, if true, causes Actions whose ReadOnly() method returns true
+	// to be routed to a replica of the relevant slot's master, rather than
+	// to the master itself. If no replica is known for the slot the master
+	// is used instead.
+	ReadOnly bool
+
+	// RouteByLatency, if true (and ReadOnly is also true), causes replica
+	// selection within a slot to favor whichever replica has the lowest
+	// recently observed PING latency, as tracked by a background
+	// go-routine.
+	RouteByLatency bool
+
+	// RouteRandomly, if true (and ReadOnly is also true, and
+	// RouteByLatency is false), causes replica selection within a slot to
+	// be uniformly random rather than always picking the first known
+	// replica.
+	RouteRandomly bool
+
+	// SyncEvery is the interval at which the Cluster will refresh its
+	// topology via CLUSTER SLOTS. If zero, it defaults to 30 seconds.
+	SyncEvery time.Duration
+
+	// ClientName, if set, is used to tag every connection of the pools the
+	// Cluster creates through its PoolFunc via CLIENT SETNAME, making those
+	// connections identifiable via CLIENT LIST on the server. If "auto", a
+	// unique name per pool is generated by radix.ResolveClientName. The
+	// dedicated READONLY connections used for replica reads when ReadOnly
+	// is set are not tagged.
+	ClientName string
+}
+
 // Cluster contains all information about a redis cluster needed to interact
 // with it, including a set of pools to each of its instances. All methods on
 // Cluster are thread-safe
 type Cluster struct {
-	pf radix.PoolFunc
+	pf   radix.PoolFunc
+	opts ClusterOpts
 
 	// used to deduplicate calls to sync
 	syncDedupe *dedupe
 
 	sync.RWMutex
-	pools map[string]radix.Client
-	tt    Topo
+	pools   map[string]radix.Client
+	tt      Topo
+	roPools map[string]*roPool // dedicated, READONLY-tagged pools used for replica reads
+
+	lat *latencyTracker
 
 	errCh   chan error // TODO expose this somehow
 	closeCh chan struct{}
@@ -66,13 +107,22 @@ type Cluster struct {
 // here and all new ones in the future. If nil is given then
 // radix.DefaultPoolFunc will be used.
 func NewCluster(pf radix.PoolFunc, addrs ...string) (*Cluster, error) {
+	return NewClusterWithOpts(pf, ClusterOpts{}, addrs...)
+}
+
+// NewClusterWithOpts is like NewCluster, but allows for specifying options
+// which affect the Cluster's behavior, such as read-only replica routing.
+func NewClusterWithOpts(pf radix.PoolFunc, o ClusterOpts, addrs ...string) (*Cluster, error) {
 	if pf == nil {
 		pf = radix.DefaultPoolFunc
 	}
 	c := &Cluster{
 		pf:         pf,
+		opts:       o,
 		syncDedupe: newDedupe(),
 		pools:      map[string]radix.Client{},
+		roPools:    map[string]*roPool{},
+		lat:        newLatencyTracker(),
 		closeCh:    make(chan struct{}),
 		errCh:      make(chan error, 1),
 	}
@@ -83,7 +133,7 @@ func NewCluster(pf radix.PoolFunc, addrs ...string) (*Cluster, error) {
 		if err != nil {
 			continue
 		}
-		c.pools[addr] = p
+		c.pools[addr] = radix.TagConnNames(p, c.opts.ClientName)
 		break
 	}
 
@@ -94,11 +144,39 @@ func NewCluster(pf radix.PoolFunc, addrs ...string) (*Cluster, error) {
 		return nil, err
 	}
 
-	go c.syncEvery(30 * time.Second) // TODO make period configurable?
+	syncEvery := o.SyncEvery
+	if syncEvery <= 0 {
+		syncEvery = 30 * time.Second
+	}
+	go c.syncEvery(syncEvery)
+
+	if o.ReadOnly && o.RouteByLatency {
+		go c.lat.pingEvery(c, 5*time.Second)
+	}
 
 	return c, nil
 }
 
+// EachClientName calls fn with the address and current CLIENT GETNAME
+// response of every pool known to the Cluster, for observability purposes.
+// Pools which fail to respond are skipped.
+func (c *Cluster) EachClientName(fn func(addr, name string)) {
+	c.RLock()
+	pools := make(map[string]radix.Client, len(c.pools))
+	for addr, p := range c.pools {
+		pools[addr] = p
+	}
+	c.RUnlock()
+
+	for addr, p := range pools {
+		var name string
+		if err := p.Do(radix.CmdNoKey("CLIENT", "GETNAME").Into(&name)); err != nil {
+			continue
+		}
+		fn(addr, name)
+	}
+}
+
 func (c *Cluster) err(err error) {
 	select {
 	case c.errCh <- err:
@@ -138,6 +216,7 @@ func (c *Cluster) pool(addr string) (radix.Client, error) {
 	if p, err = c.pf("tcp", addr); err != nil {
 		return nil, err
 	}
+	p = radix.TagConnNames(p, c.opts.ClientName)
 
 	// we've made a new pool, but we need to double-check someone else didn't
 	// make one at the same time and add it in first. If they did, close this
@@ -165,7 +244,7 @@ func (c *Cluster) Topo() (Topo, error) {
 
 func (c *Cluster) topo(p radix.Client) (Topo, error) {
 	var tt Topo
-	err := p.Do(radix.Cmd(&tt, "CLUSTER", "SLOTS"))
+	err := p.Do(radix.CmdNoKey("CLUSTER", "SLOTS").Into(&tt))
 	return tt, err
 }
 
@@ -235,8 +314,15 @@ func (c *Cluster) syncEvery(d time.Duration) {
 }
 
 func (c *Cluster) addrForKey(key []byte) string {
+	n := c.nodeForKey(key)
+	return n.Addr
+}
+
+// nodeForKey returns the master Node responsible for the slot the given key
+// falls in, or a zero Node if key is nil or no such Node is known.
+func (c *Cluster) nodeForKey(key []byte) Node {
 	if key == nil {
-		return ""
+		return Node{}
 	}
 	s := Slot(key)
 	c.RLock()
@@ -244,36 +330,120 @@ func (c *Cluster) addrForKey(key []byte) string {
 	for _, t := range c.tt {
 		for _, slot := range t.Slots {
 			if s >= slot[0] && s < slot[1] {
-				return t.Addr
+				return t
 			}
 		}
 	}
-	return ""
+	return Node{}
 }
 
 const doAttempts = 5
 
+// clusterAction is implemented by Actions (e.g. ClusterPipeline) which need
+// direct access to the Cluster itself to run, rather than being routed to a
+// single node based on their Key().
+type clusterAction interface {
+	runOnCluster(c *Cluster) error
+}
+
 // Do performs an Action on a redis instance in the cluster, with the instance
 // being determeined by the key returned from the Action's Key() method.
 //
+// If ReadOnly is set in the Cluster's ClusterOpts and a is a radix.CmdAction
+// whose ReadOnly method reports true, it will be routed to a replica of the
+// relevant slot's master instead of the master itself, chosen according to
+// RouteByLatency and RouteRandomly.
+//
 // If the Action is a CmdAction then Cluster will handled MOVED and ASK errors
 // correctly, for other Action types those errors will be returned as is.
 func (c *Cluster) Do(a radix.Action) error {
-	return c.doInner(a, c.addrForKey(a.Key()), false, doAttempts)
+	return c.DoCtx(context.Background(), a)
 }
 
-func (c *Cluster) doInner(a radix.Action, addr string, ask bool, attempts int) error {
-	p, err := c.pool(addr)
-	if err != nil {
+// DoCtx is like Do, but will abort early with ctx.Err() if ctx is canceled
+// or expires, including between MOVED/ASK retries. If a implements
+// radix.ActionCtx, its RunCtx method is used instead of Run so the context
+// can also be honored by the underlying connection (e.g. to cancel a
+// blocking command). A ClusterPipeline only has ctx checked before it
+// starts: its own MOVED/ASK redispatches do not check ctx, and its
+// commands are not run under ctx.
+func (c *Cluster) DoCtx(ctx context.Context, a radix.Action) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ca, ok := a.(clusterAction); ok {
+		return ca.runOnCluster(c)
+	}
+
+	n := c.nodeForKey(a.Key())
+	addr := n.Addr
+	ro := false
+
+	if c.opts.ReadOnly {
+		if cmd, ok := a.(radix.CmdAction); ok && cmd.ReadOnly() {
+			if raddr := c.replicaAddr(n); raddr != "" {
+				addr, ro = raddr, true
+			}
+		}
+	}
+
+	return c.doInner(ctx, a, addr, ro, false, doAttempts)
+}
+
+// replicaAddr picks a replica address out of n's known replicas, according
+// to the Cluster's RouteByLatency/RouteRandomly options. It returns "" if n
+// has no known replicas.
+func (c *Cluster) replicaAddr(n Node) string {
+	if len(n.Replicas) == 0 {
+		return ""
+	}
+
+	switch {
+	case c.opts.RouteByLatency:
+		return c.lat.fastest(n.Replicas).Addr
+	case c.opts.RouteRandomly:
+		return n.Replicas[rand.Intn(len(n.Replicas))].Addr
+	default:
+		return n.Replicas[0].Addr
+	}
+}
+
+// roPool returns the dedicated read-only pool used for replica reads to
+// addr, creating one if it doesn't exist yet. Unlike the regular pools used
+// for master traffic, a roPool issues READONLY on every connection it dials,
+// right after dialing it and before the connection is ever handed out -
+// see roPool's own doc comment for why this can't just be bolted onto a
+// regular pool via Do.
+func (c *Cluster) roPool(addr string) *roPool {
+	c.Lock()
+	defer c.Unlock()
+	if p, ok := c.roPools[addr]; ok {
+		return p
+	}
+	p := newROPool(addr)
+	c.roPools[addr] = p
+	return p
+}
+
+func (c *Cluster) doInner(ctx context.Context, a radix.Action, addr string, ro, ask bool, attempts int) error {
+	var p radix.Client
+	var err error
+	if ro {
+		p = c.roPool(addr)
+	} else if p, err = c.pool(addr); err != nil {
 		return err
 	}
 
 	err = p.Do(radix.WithConn(a.Key(), func(conn radix.Conn) error {
 		if ask {
-			if err := radix.CmdNoKey(nil, "ASKING").Run(conn); err != nil {
+			if err := radix.CmdNoKey("ASKING").Run(conn); err != nil {
 				return err
 			}
 		}
+		if ca, ok := a.(radix.ActionCtx); ok {
+			return ca.RunCtx(ctx, conn)
+		}
 		return a.Run(conn)
 	}))
 
@@ -310,7 +480,13 @@ func (c *Cluster) doInner(a radix.Action, addr string, ask bool, attempts int) e
 		return errors.New("cluster action redirected too many times")
 	}
 
-	return c.doInner(a, addr, ask, attempts)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// a MOVED/ASK redirect always re-routes to whatever node the error names,
+	// which is the master importing/owning the slot, not a replica
+	return c.doInner(ctx, a, addr, false, ask, attempts)
 }
 
 // Close cleans up all goroutines spawned by Cluster and closes all of its
@@ -324,5 +500,8 @@ func (c *Cluster) Close() {
 	for _, p := range c.pools {
 		p.Close()
 	}
+	for _, p := range c.roPools {
+		p.Close()
+	}
 	return
 }
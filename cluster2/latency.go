@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	radix "github.com/mediocregopher/radix.v2"
+)
+
+// ewmaAlpha is the smoothing factor used when folding a new latency sample
+// into a node's running average. Higher values weight recent samples more
+// heavily.
+const ewmaAlpha = 0.3
+
+// latencyTracker keeps a running average latency for every known node
+// address, used to implement ClusterOpts.RouteByLatency.
+type latencyTracker struct {
+	l   sync.RWMutex
+	avg map[string]time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{avg: map[string]time.Duration{}}
+}
+
+func (lt *latencyTracker) record(addr string, d time.Duration) {
+	lt.l.Lock()
+	defer lt.l.Unlock()
+	prev, ok := lt.avg[addr]
+	if !ok {
+		lt.avg[addr] = d
+		return
+	}
+	lt.avg[addr] = time.Duration(ewmaAlpha*float64(d) + (1-ewmaAlpha)*float64(prev))
+}
+
+// fastest returns whichever of the given Nodes has the lowest recorded
+// average latency. A Node with no recorded latency yet counts as zero.
+func (lt *latencyTracker) fastest(nodes []Node) Node {
+	lt.l.RLock()
+	defer lt.l.RUnlock()
+
+	best := nodes[0]
+	bestLat := lt.avg[best.Addr]
+	for _, n := range nodes[1:] {
+		if d := lt.avg[n.Addr]; d < bestLat {
+			best, bestLat = n, d
+		}
+	}
+	return best
+}
+
+// pingEvery periodically PINGs every known replica in c's topology and
+// records the round-trip latency. It's meant to be run in its own
+// go-routine for the lifetime of the Cluster.
+func (lt *latencyTracker) pingEvery(c *Cluster, d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			lt.pingAll(c)
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+func (lt *latencyTracker) pingAll(c *Cluster) {
+	c.RLock()
+	var addrs []string
+	for _, n := range c.tt {
+		for _, r := range n.Replicas {
+			addrs = append(addrs, r.Addr)
+		}
+	}
+	c.RUnlock()
+
+	for _, addr := range addrs {
+		p, err := c.pool(addr)
+		if err != nil {
+			continue
+		}
+		start := time.Now()
+		if err := p.Do(radix.CmdNoKey("PING")); err != nil {
+			continue
+		}
+		lt.record(addr, time.Since(start))
+	}
+}
@@ -0,0 +1,22 @@
+package cluster
+
+import (
+	"time"
+
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyTrackerFastest(t *T) {
+	lt := newLatencyTracker()
+	nodes := []Node{{Addr: "a"}, {Addr: "b"}, {Addr: "c"}}
+
+	// with no samples recorded, the first node should be returned
+	assert.Equal(t, "a", lt.fastest(nodes).Addr)
+
+	lt.record("a", 50*time.Millisecond)
+	lt.record("b", 5*time.Millisecond)
+	lt.record("c", 20*time.Millisecond)
+	assert.Equal(t, "b", lt.fastest(nodes).Addr)
+}
@@ -0,0 +1,286 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	radix "github.com/mediocregopher/radix.v2"
+)
+
+// ClusterPipeline is a radix.Action, returned by Pipeline and Cluster's
+// Pipeline method, which fans a set of commands out across whichever nodes
+// own their keys. It can only be run via a Cluster's Do method.
+type ClusterPipeline struct {
+	cmds      []radix.RawCmd
+	broadcast bool
+}
+
+// Pipeline returns a ClusterPipeline which, when passed to a Cluster's Do
+// method, groups cmds by the node responsible for each command's key, issues
+// a real radix.Pipeline to each of those nodes concurrently, and lets each
+// RawCmd's Into target be filled in as normal. Commands with no key (or
+// whose key isn't in a known slot) are, by default, sent to a single
+// randomly chosen node; see Broadcast to change that.
+func Pipeline(cmds ...radix.RawCmd) *ClusterPipeline {
+	return &ClusterPipeline{cmds: cmds}
+}
+
+// Pipeline is a convenience wrapper around the package-level Pipeline
+// function.
+func (c *Cluster) Pipeline(cmds ...radix.RawCmd) *ClusterPipeline {
+	return Pipeline(cmds...)
+}
+
+// Broadcast configures cp so that commands with no key are sent to every
+// known node, rather than to a single random one. It returns cp for
+// chaining.
+func (cp *ClusterPipeline) Broadcast(b bool) *ClusterPipeline {
+	cp.broadcast = b
+	return cp
+}
+
+// Key implements the radix.Action interface. It always returns nil, since a
+// ClusterPipeline may touch any number of nodes.
+func (cp *ClusterPipeline) Key() []byte { return nil }
+
+// Run implements the radix.Action interface. ClusterPipeline can only
+// meaningfully be run via Cluster.Do, which detects it and calls
+// runOnCluster directly instead.
+func (cp *ClusterPipeline) Run(conn radix.Conn) error {
+	return errors.New("cluster: ClusterPipeline can only be run via Cluster.Do")
+}
+
+func (cp *ClusterPipeline) runOnCluster(c *Cluster) error {
+	groups, err := c.pipelineGroups(cp.cmds, cp.broadcast)
+	if err != nil {
+		return err
+	}
+	return c.runPipelineGroups(groups, cp.broadcast, doAttempts)
+}
+
+// pipelineGroup is a set of commands destined for a single node, and whether
+// ASKING needs to be sent to that node before they're run (i.e. this group
+// was built from the address of an ASK redirect, rather than from the
+// cluster's own topology).
+type pipelineGroup struct {
+	cmds []radix.RawCmd
+	ask  bool
+}
+
+// pipelineGroups splits cmds up by the node responsible for each command's
+// key, per the cluster's current topology.
+func (c *Cluster) pipelineGroups(cmds []radix.RawCmd, broadcast bool) (map[string]*pipelineGroup, error) {
+	groups := map[string]*pipelineGroup{}
+	addTo := func(addr string, cmd radix.RawCmd) {
+		g, ok := groups[addr]
+		if !ok {
+			g = &pipelineGroup{}
+			groups[addr] = g
+		}
+		g.cmds = append(g.cmds, cmd)
+	}
+
+	var allAddrs []string
+	for _, cmd := range cmds {
+		addr := c.addrForKey(cmd.Key())
+		if addr != "" {
+			addTo(addr, cmd)
+			continue
+		}
+
+		// no known node for this command's key (or it has no key at all)
+		if allAddrs == nil {
+			allAddrs = c.knownAddrs()
+		}
+		if len(allAddrs) == 0 {
+			return nil, errors.New("cluster: no nodes known to pipeline to")
+		}
+		if broadcast {
+			for _, a := range allAddrs {
+				addTo(a, cmd)
+			}
+		} else {
+			addTo(allAddrs[rand.Intn(len(allAddrs))], cmd)
+		}
+	}
+	return groups, nil
+}
+
+// multiCmd is a radix.Action which runs cmds as a single pipelined batch via
+// radix.RunEach and keeps the per-command results, so the caller can tell
+// exactly which command(s) a partial MOVED/ASK redirect actually affected,
+// rather than only the first error in the batch.
+type multiCmd struct {
+	cmds []radix.RawCmd
+	errs []error
+}
+
+func (m *multiCmd) Key() []byte { return nil }
+
+func (m *multiCmd) Run(c radix.Conn) error {
+	m.errs = radix.RunEach(m.cmds, c)
+	for _, err := range m.errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPipelineGroups runs each group's sub-pipeline concurrently against the
+// node it's addressed to (sending ASKING first for groups built from an ASK
+// redirect). Unlike redispatching a whole group on any redirect - which
+// would re-run already-succeeded, non-idempotent commands a second time -
+// only the specific command(s) that actually came back MOVED or ASK are
+// redispatched, up to attempts times: MOVED redirects are re-grouped
+// according to the cluster's topology (synced first, since a MOVED means
+// the topology is now stale), while ASK redirects go straight to the
+// address named in the error, preceded by ASKING.
+func (c *Cluster) runPipelineGroups(groups map[string]*pipelineGroup, broadcast bool, attempts int) error {
+	if attempts <= 0 {
+		return errors.New("cluster: pipeline redirected too many times")
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		firstErr  error
+		movedCmds []radix.RawCmd
+		askRedo   = map[string][]radix.RawCmd{}
+		needSync  bool
+	)
+
+	for addr, g := range groups {
+		wg.Add(1)
+		go func(addr string, g *pipelineGroup) {
+			defer wg.Done()
+
+			p, err := c.pool(addr)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+
+			pipelineCmds := g.cmds
+			offset := 0
+			if g.ask {
+				pipelineCmds = append([]radix.RawCmd{radix.CmdNoKey("ASKING")}, pipelineCmds...)
+				offset = 1
+			}
+
+			mc := &multiCmd{cmds: pipelineCmds}
+			if err := p.Do(mc); err != nil && mc.errs == nil {
+				// never even got to run the batch (e.g. couldn't borrow a
+				// connection) - nothing to redrive selectively, fail the
+				// whole group
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+
+			for i, cmdErr := range mc.errs {
+				if i < offset {
+					// the ASKING command itself; a failure here means the
+					// connection/node is bad, not that a specific command
+					// needs redriving
+					if cmdErr != nil {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = cmdErr
+						}
+						mu.Unlock()
+						return
+					}
+					continue
+				}
+				if cmdErr == nil {
+					continue
+				}
+
+				origCmd := g.cmds[i-offset]
+				msg := cmdErr.Error()
+				if strings.HasPrefix(msg, "MOVED ") {
+					mu.Lock()
+					needSync = true
+					movedCmds = append(movedCmds, origCmd)
+					mu.Unlock()
+					continue
+				}
+				if strings.HasPrefix(msg, "ASK ") {
+					msgParts := strings.Split(msg, " ")
+					if len(msgParts) < 3 {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = fmt.Errorf("malformed ASK error %q", msg)
+						}
+						mu.Unlock()
+						continue
+					}
+					mu.Lock()
+					askAddr := msgParts[2]
+					askRedo[askAddr] = append(askRedo[askAddr], origCmd)
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = cmdErr
+				}
+				mu.Unlock()
+			}
+		}(addr, g)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if len(movedCmds) == 0 && len(askRedo) == 0 {
+		return nil
+	}
+
+	if needSync {
+		if err := c.Sync(); err != nil {
+			return err
+		}
+	}
+
+	nextGroups, err := c.pipelineGroups(movedCmds, broadcast)
+	if err != nil {
+		return err
+	}
+	for addr, cmds := range askRedo {
+		g, ok := nextGroups[addr]
+		if !ok {
+			g = &pipelineGroup{}
+			nextGroups[addr] = g
+		}
+		g.ask = true
+		g.cmds = append(g.cmds, cmds...)
+	}
+
+	return c.runPipelineGroups(nextGroups, broadcast, attempts-1)
+}
+
+// knownAddrs returns the addresses of all masters in c's current topology.
+func (c *Cluster) knownAddrs() []string {
+	c.RLock()
+	defer c.RUnlock()
+	addrs := make([]string, 0, len(c.tt))
+	for _, n := range c.tt {
+		addrs = append(addrs, n.Addr)
+	}
+	return addrs
+}
@@ -0,0 +1,47 @@
+package cluster
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	radix "github.com/mediocregopher/radix.v2"
+)
+
+func TestPipelineGroupsByKey(t *T) {
+	c := &Cluster{
+		tt: Topo{
+			{Addr: "10.0.0.1:6379", Slots: [][2]uint16{{0, NumSlots - 1}}},
+		},
+	}
+	c.pools = map[string]radix.Client{}
+
+	cmds := []radix.RawCmd{
+		radix.Cmd("GET", "foo"),
+		radix.Cmd("GET", "bar"),
+	}
+	groups, err := c.pipelineGroups(cmds, false)
+	require.Nil(t, err)
+	require.Len(t, groups, 1)
+	assert.Len(t, groups["10.0.0.1:6379"].cmds, 2)
+	assert.False(t, groups["10.0.0.1:6379"].ask)
+}
+
+func TestPipelineGroupsNoKeyBroadcast(t *T) {
+	c := &Cluster{
+		tt: Topo{
+			{Addr: "10.0.0.1:6379", Slots: [][2]uint16{{0, 8191}}},
+			{Addr: "10.0.0.2:6379", Slots: [][2]uint16{{8192, NumSlots - 1}}},
+		},
+	}
+	c.pools = map[string]radix.Client{}
+
+	cmds := []radix.RawCmd{radix.CmdNoKey("PING")}
+	groups, err := c.pipelineGroups(cmds, true)
+	require.Nil(t, err)
+	require.Len(t, groups, 2)
+	for _, g := range groups {
+		assert.Len(t, g.cmds, 1)
+	}
+}
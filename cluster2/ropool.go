@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"sync"
+
+	radix "github.com/mediocregopher/radix.v2"
+)
+
+// roPool is a minimal radix.Client which lazily dials its own connections to
+// a single address and issues READONLY on each one right after dialing it,
+// before it's ever handed out to a caller - unlike a regular pool's Do,
+// which would only reach whichever single connection it happens to borrow.
+type roPool struct {
+	addr string
+
+	l     sync.Mutex
+	conns []radix.Conn
+}
+
+func newROPool(addr string) *roPool {
+	return &roPool{addr: addr}
+}
+
+func (p *roPool) dial() (radix.Conn, error) {
+	conn, err := radix.Dial("tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := radix.CmdNoKey("READONLY").Run(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (p *roPool) get() (radix.Conn, error) {
+	p.l.Lock()
+	if n := len(p.conns); n > 0 {
+		conn := p.conns[n-1]
+		p.conns = p.conns[:n-1]
+		p.l.Unlock()
+		return conn, nil
+	}
+	p.l.Unlock()
+	return p.dial()
+}
+
+func (p *roPool) put(conn radix.Conn) {
+	p.l.Lock()
+	p.conns = append(p.conns, conn)
+	p.l.Unlock()
+}
+
+// Do implements the method for the radix.Client interface. a is run against
+// a connection which has already had READONLY issued on it; the connection
+// is returned to the pool for reuse on success, or closed on error.
+func (p *roPool) Do(a radix.Action) error {
+	conn, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	if err := a.Run(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	p.put(conn)
+	return nil
+}
+
+// Close closes every connection currently idle in the pool.
+func (p *roPool) Close() error {
+	p.l.Lock()
+	defer p.l.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+	return nil
+}
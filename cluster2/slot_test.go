@@ -0,0 +1,19 @@
+package cluster
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlotHashtag(t *T) {
+	// keys sharing a hashtag must land in the same slot
+	a := Slot([]byte("{user1000}.following"))
+	b := Slot([]byte("{user1000}.followers"))
+	assert.Equal(t, a, b)
+}
+
+func TestSlotRange(t *T) {
+	s := Slot([]byte("foo"))
+	assert.True(t, s < NumSlots)
+}
@@ -0,0 +1,129 @@
+package cluster
+
+import "fmt"
+
+// Node describes a single redis instance participating in a cluster, along
+// with the hash slot ranges it (or, for a replica, its master) is
+// responsible for.
+type Node struct {
+	Addr  string
+	Slots [][2]uint16
+
+	// Replicas holds the replicas of this Node, as reported by CLUSTER
+	// SLOTS. It will always be empty on a Node which is itself a replica.
+	Replicas []Node
+}
+
+// Topo describes the topology of a redis cluster, as determined by a
+// CLUSTER SLOTS call. It contains one Node per master, each carrying its own
+// set of replicas.
+type Topo []Node
+
+// Map returns the Topo as a map of node address to Node, for both masters
+// and replicas.
+func (tt Topo) Map() map[string]Node {
+	m := make(map[string]Node, len(tt))
+	for _, t := range tt {
+		m[t.Addr] = t
+		for _, r := range t.Replicas {
+			m[r.Addr] = r
+		}
+	}
+	return m
+}
+
+// UnmarshalRESP implements radix.Unmarshaler, decoding a CLUSTER SLOTS reply.
+// Each entry in the reply is a single slot range; since a master with
+// multiple ranges appears as one entry per range, entries for the same
+// master address are merged into a single Node.
+func (tt *Topo) UnmarshalRESP(reply interface{}) error {
+	entries, ok := reply.([]interface{})
+	if !ok {
+		return fmt.Errorf("cluster: unexpected CLUSTER SLOTS reply %T", reply)
+	}
+
+	byAddr := map[string]*Node{}
+	var order []string
+
+	for _, e := range entries {
+		fields, ok := e.([]interface{})
+		if !ok || len(fields) < 3 {
+			return fmt.Errorf("cluster: malformed CLUSTER SLOTS entry %v", e)
+		}
+
+		start, err := slotField(fields[0])
+		if err != nil {
+			return err
+		}
+		end, err := slotField(fields[1])
+		if err != nil {
+			return err
+		}
+
+		addr, err := nodeAddr(fields[2])
+		if err != nil {
+			return err
+		}
+
+		n, ok := byAddr[addr]
+		if !ok {
+			n = &Node{Addr: addr}
+			byAddr[addr] = n
+			order = append(order, addr)
+		}
+		// CLUSTER SLOTS gives an inclusive end slot; Node.Slots is half-open.
+		n.Slots = append(n.Slots, [2]uint16{start, end + 1})
+
+		for _, rf := range fields[3:] {
+			raddr, err := nodeAddr(rf)
+			if err != nil {
+				return err
+			}
+			// every range entry repeats its master's replicas, so only add
+			// ones not already known
+			known := false
+			for _, r := range n.Replicas {
+				if r.Addr == raddr {
+					known = true
+					break
+				}
+			}
+			if !known {
+				n.Replicas = append(n.Replicas, Node{Addr: raddr})
+			}
+		}
+	}
+
+	out := make(Topo, len(order))
+	for i, addr := range order {
+		out[i] = *byAddr[addr]
+	}
+	*tt = out
+	return nil
+}
+
+// nodeAddr pulls the "ip:port" address out of a CLUSTER SLOTS master/replica
+// field, which is itself a 2+ element array of [ip, port, ...].
+func nodeAddr(v interface{}) (string, error) {
+	fields, ok := v.([]interface{})
+	if !ok || len(fields) < 2 {
+		return "", fmt.Errorf("cluster: malformed node entry %v", v)
+	}
+	ip, ok := fields[0].([]byte)
+	if !ok {
+		return "", fmt.Errorf("cluster: malformed node ip %v", fields[0])
+	}
+	port, err := slotField(fields[1])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d", ip, port), nil
+}
+
+func slotField(v interface{}) (uint16, error) {
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("cluster: expected integer field, got %T", v)
+	}
+	return uint16(n), nil
+}
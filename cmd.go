@@ -0,0 +1,164 @@
+package radix
+
+import (
+	"bufio"
+	"strings"
+)
+
+// RawCmd is a concrete Action for a single redis command, as returned by
+// Cmd, CmdNoKey, and LuaCmd. It implements CmdAction.
+type RawCmd struct {
+	cmd  string
+	key  []byte
+	args []interface{}
+	rcv  interface{}
+}
+
+// Cmd creates a RawCmd for the given command and arguments. The first
+// argument, if any, is taken to be the key the command operates on; use
+// CmdNoKey for commands which don't operate on a key (e.g. PING, CLIENT
+// SETNAME) or which need to be broadcast/routed some other way.
+func Cmd(cmd string, args ...interface{}) RawCmd {
+	rc := RawCmd{cmd: cmd, args: args}
+	if len(args) > 0 {
+		rc.key = argBytes(args[0])
+	}
+	return rc
+}
+
+// CmdNoKey is like Cmd, but the returned RawCmd's Key method always returns
+// nil, regardless of args.
+func CmdNoKey(cmd string, args ...interface{}) RawCmd {
+	return RawCmd{cmd: cmd, args: args}
+}
+
+// LuaCmd returns a RawCmd which runs script via EVAL, with the given keys
+// and args passed through as KEYS and ARGV. Its Key is the first of keys, or
+// nil if keys is empty.
+func LuaCmd(script string, keys []string, args ...interface{}) RawCmd {
+	cmdArgs := make([]interface{}, 0, len(keys)+len(args)+2)
+	cmdArgs = append(cmdArgs, script, len(keys))
+	for _, k := range keys {
+		cmdArgs = append(cmdArgs, k)
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	var key []byte
+	if len(keys) > 0 {
+		key = []byte(keys[0])
+	}
+	return RawCmd{cmd: "EVAL", key: key, args: cmdArgs}
+}
+
+// Into sets dst as the target rc's reply will be decoded into when rc is
+// run, and returns rc for chaining, e.g. Cmd("GET", key).Into(&dst).Run(c).
+func (rc RawCmd) Into(dst interface{}) RawCmd {
+	rc.rcv = dst
+	return rc
+}
+
+// Key implements the Action interface.
+func (rc RawCmd) Key() []byte { return rc.key }
+
+// ReadOnly implements the CmdAction interface.
+func (rc RawCmd) ReadOnly() bool { return IsReadOnlyCmd(strings.ToUpper(rc.cmd)) }
+
+// Run implements the Action interface.
+func (rc RawCmd) Run(c Conn) error {
+	if err := writeCmd(c, rc.cmd, rc.args); err != nil {
+		return err
+	}
+	reply, err := readReply(bufferedReader(c))
+	if err != nil {
+		return err
+	}
+	return into(reply, rc.rcv)
+}
+
+// bufferedReader returns the bufio.Reader a Conn returned by Dial keeps for
+// reply decoding, falling back to a fresh one for any other Conn
+// implementation. Reusing the same Reader across calls matters: a fresh
+// bufio.Reader may read ahead past the end of one reply into bytes
+// belonging to the next, which would otherwise be lost when that Reader is
+// discarded.
+func bufferedReader(c Conn) *bufio.Reader {
+	if cn, ok := c.(*conn); ok {
+		return cn.br
+	}
+	return bufio.NewReader(c)
+}
+
+// pipelineAction is the Action returned by Pipeline.
+type pipelineAction []RawCmd
+
+// Pipeline returns an Action which writes every one of cmds to the Conn up
+// front, then reads back each reply in turn, filling in each RawCmd's Into
+// target as normal. This saves a round trip per command compared to running
+// them one at a time.
+func Pipeline(cmds ...RawCmd) Action {
+	return pipelineAction(cmds)
+}
+
+// Key implements the Action interface. It always returns nil, since a
+// pipeline may contain commands for any number of keys.
+func (p pipelineAction) Key() []byte { return nil }
+
+// Run implements the Action interface.
+func (p pipelineAction) Run(c Conn) error {
+	for _, err := range RunEach([]RawCmd(p), c) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunEach writes every one of cmds to c as a single pipelined batch, then
+// reads and decodes each reply in turn, returning one error per command (a
+// nil entry means that command succeeded). It's lower-level than Pipeline,
+// which only surfaces the first error it sees: callers which need to know
+// exactly which command(s) in a batch failed - such as cluster2, redriving
+// only the specific commands a partial MOVED/ASK redirect actually affected
+// - use RunEach directly instead.
+func RunEach(cmds []RawCmd, c Conn) []error {
+	errs := make([]error, len(cmds))
+
+	for _, rc := range cmds {
+		if err := writeCmd(c, rc.cmd, rc.args); err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return errs
+		}
+	}
+
+	br := bufferedReader(c)
+	for i, rc := range cmds {
+		reply, err := readReply(br)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		errs[i] = into(reply, rc.rcv)
+	}
+	return errs
+}
+
+// withConnAction is the Action returned by WithConn.
+type withConnAction struct {
+	key []byte
+	fn  func(c Conn) error
+}
+
+// WithConn returns an Action which simply calls fn with whatever Conn it's
+// given, rather than sending a command of its own. Passed to a Client's Do,
+// this borrows a single connection and lets fn run several commands against
+// it directly - TagConnNames and Cluster's own redirect handling both use
+// this to combine a setup step (CLIENT SETNAME, ASKING) with the
+// actual Action on the same connection.
+func WithConn(key []byte, fn func(c Conn) error) Action {
+	return withConnAction{key: key, fn: fn}
+}
+
+func (w withConnAction) Key() []byte      { return w.key }
+func (w withConnAction) Run(c Conn) error { return w.fn(c) }
@@ -0,0 +1,74 @@
+package radix
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn is a single connection to a redis instance. It's a thin wrapper
+// around net.Conn; Actions read and write directly against it.
+type Conn interface {
+	net.Conn
+}
+
+// conn is the concrete Conn implementation returned by Dial. It wraps a
+// single net.Conn, along with a buffered reader RawCmd.Run/RunEach reuse
+// across calls rather than discarding read-ahead bytes belonging to a later
+// reply every time one is created.
+type conn struct {
+	net.Conn
+	br *bufio.Reader
+
+	l      sync.Mutex
+	closed bool
+}
+
+// Dial opens a connection to a redis instance at addr, using the given
+// network ("tcp" or "unix").
+func Dial(network, addr string) (Conn, error) {
+	nc, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: nc, br: bufio.NewReader(nc)}, nil
+}
+
+func (c *conn) Close() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.Conn.Close()
+}
+
+// DoCtx implements the ContextConn interface. It derives a deadline from
+// ctx, if any, and applies it to the connection before running a, clearing
+// it again once a.Run returns. If ctx is canceled while a.Run is still
+// blocked - e.g. waiting on a BLPOP/XREAD reply with no deadline of its own
+// - a background go-routine closes the connection so the blocked read
+// unblocks with an error instead of hanging forever.
+func (c *conn) DoCtx(ctx context.Context, a Action) error {
+	if dl, ok := ctx.Deadline(); ok {
+		if err := c.SetDeadline(dl); err != nil {
+			return err
+		}
+		defer c.SetDeadline(time.Time{})
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	return a.Run(c)
+}
@@ -0,0 +1,68 @@
+package radix
+
+import "context"
+
+// ActionCtx is implemented by Actions which support being canceled or
+// deadlined via a context.Context, such as those returned by WithContext.
+// Cluster.DoCtx and Sentinel.DoCtx will use RunCtx instead of Run when
+// running such an Action.
+type ActionCtx interface {
+	Action
+	RunCtx(ctx context.Context, c Conn) error
+}
+
+// ContextConn is implemented by Conn implementations (such as the one
+// returned by Dial) which support running an Action under a context.
+// DoCtx sets a deadline derived from ctx before writing (clearing it once
+// the Action completes) and closes the connection if ctx is canceled while
+// a blocking command, like BLPOP or XREAD, is still waiting on a reply.
+type ContextConn interface {
+	Conn
+	DoCtx(ctx context.Context, a Action) error
+}
+
+// ctxAction wraps an Action with a context, implementing ActionCtx. It's
+// returned by WithContext.
+type ctxAction struct {
+	Action
+	ctx context.Context
+}
+
+// WithContext returns a version of a which honors ctx's deadline and
+// cancellation when run via DoCtx, on either a ContextConn directly or
+// through Cluster.DoCtx/Sentinel.DoCtx.
+func WithContext(a Action, ctx context.Context) ActionCtx {
+	return ctxAction{Action: a, ctx: ctx}
+}
+
+// WithContext is the same as the package-level WithContext(rc, ctx), but
+// lets a command bind its own context directly, e.g.
+// Cmd("GET", key).WithContext(ctx).Run(c).
+func (rc RawCmd) WithContext(ctx context.Context) ActionCtx {
+	return WithContext(rc, ctx)
+}
+
+// RunCtx implements the ActionCtx interface. The context bound by
+// WithContext takes precedence over ctx, since it's the one the caller
+// actually attached to this Action; ctx is only used as a fallback for
+// Actions that didn't go through WithContext at all. If c is a ContextConn,
+// the wrapped Action is run through its DoCtx so the connection's deadline
+// and cancellation handling apply; otherwise it just falls back to Run.
+func (ca ctxAction) RunCtx(ctx context.Context, c Conn) error {
+	if ca.ctx != nil {
+		ctx = ca.ctx
+	}
+	if cc, ok := c.(ContextConn); ok {
+		return cc.DoCtx(ctx, ca.Action)
+	}
+	return ca.Action.Run(c)
+}
+
+// Run implements the Action interface, running the wrapped Action under the
+// context bound by WithContext, the same way RunCtx does.
+func (ca ctxAction) Run(c Conn) error {
+	if ca.ctx == nil {
+		return ca.Action.Run(c)
+	}
+	return ca.RunCtx(ca.ctx, c)
+}
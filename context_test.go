@@ -0,0 +1,66 @@
+package radix
+
+import (
+	"context"
+	"net"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingAction blocks in Run until the connection it's given is closed or
+// has data to read, simulating something like a BLPOP.
+type blockingAction struct{}
+
+func (blockingAction) Key() []byte { return nil }
+
+func (blockingAction) Run(c Conn) error {
+	var buf [1]byte
+	_, err := c.Read(buf[:])
+	return err
+}
+
+func TestConnDoCtxCancel(t *T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &conn{Conn: client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.DoCtx(ctx, blockingAction{}) }()
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("DoCtx did not return after context was canceled")
+	}
+}
+
+func TestWithContextUsesBoundContext(t *T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &conn{Conn: client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := WithContext(blockingAction{}, ctx)
+
+	errCh := make(chan error, 1)
+	// RunCtx is given context.Background() here, deliberately distinct from
+	// ctx, to confirm the Action's own bound context (ctx) is what actually
+	// governs cancellation, not whatever the caller happens to pass in.
+	go func() { errCh <- a.RunCtx(context.Background(), c) }()
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		require.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("RunCtx did not return after the bound context was canceled")
+	}
+}
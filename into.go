@@ -0,0 +1,103 @@
+package radix
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Unmarshaler is implemented by types (such as cluster.Topo) whose reply
+// shape is too specific for the generic decoding below, letting them decode
+// themselves from a raw RESP reply when used as a RawCmd's Into target.
+type Unmarshaler interface {
+	UnmarshalRESP(reply interface{}) error
+}
+
+// into decodes reply into dst, which must either implement Unmarshaler or be
+// a non-nil pointer to one of the types intoValue understands. It's used by
+// RawCmd.Run to fill in the Into target, if any was given.
+func into(reply interface{}, dst interface{}) error {
+	if dst == nil {
+		return nil
+	}
+	if u, ok := dst.(Unmarshaler); ok {
+		return u.UnmarshalRESP(reply)
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("radix: Into target must be a non-nil pointer")
+	}
+	return intoValue(reply, v.Elem())
+}
+
+func intoValue(reply interface{}, v reflect.Value) error {
+	switch r := reply.(type) {
+	case nil:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case []byte:
+		return intoBytes(r, v)
+	case int64:
+		return intoInt64(r, v)
+	case []interface{}:
+		return intoArray(r, v)
+	default:
+		return fmt.Errorf("radix: can't decode %T into %s", reply, v.Type())
+	}
+}
+
+func intoBytes(b []byte, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(b))
+		return nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Bool:
+		bo, err := strconv.ParseBool(string(b))
+		if err != nil {
+			return err
+		}
+		v.SetBool(bo)
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+	}
+	return fmt.Errorf("radix: can't decode bulk string into %s", v.Type())
+}
+
+func intoInt64(n int64, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		v.SetInt(n)
+		return nil
+	case reflect.String:
+		v.SetString(strconv.FormatInt(n, 10))
+		return nil
+	}
+	return fmt.Errorf("radix: can't decode integer into %s", v.Type())
+}
+
+func intoArray(arr []interface{}, v reflect.Value) error {
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("radix: can't decode array into %s", v.Type())
+	}
+	out := reflect.MakeSlice(v.Type(), len(arr), len(arr))
+	for i, el := range arr {
+		if err := intoValue(el, out.Index(i)); err != nil {
+			return err
+		}
+	}
+	v.Set(out)
+	return nil
+}
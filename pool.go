@@ -0,0 +1,96 @@
+package radix
+
+import "sync"
+
+// Client is anything which can run an Action, whether that's a single Pool
+// of connections to one redis instance or something which fans an Action
+// out further still, like cluster.Cluster, sentinel.Sentinel, or ring.Ring.
+type Client interface {
+	Do(a Action) error
+	Close() error
+}
+
+// PoolFunc creates a Client for a given network/address pair. It's the
+// extension point used by cluster2, sentinel, and ring to create their
+// per-node pools.
+type PoolFunc func(network, addr string) (Client, error)
+
+// DefaultPoolSize is the number of idle connections a Pool created by
+// DefaultPoolFunc will keep open to its address.
+const DefaultPoolSize = 10
+
+// DefaultPoolFunc is the PoolFunc used wherever one isn't given explicitly.
+var DefaultPoolFunc PoolFunc = func(network, addr string) (Client, error) {
+	return NewPool(network, addr, DefaultPoolSize)
+}
+
+// Pool is a Client backed by up to size persistent connections to a single
+// redis instance. Connections are dialed lazily, the first time they're
+// needed, and reused across Do calls.
+type Pool struct {
+	network, addr string
+	size          int
+
+	l      sync.Mutex
+	conns  []Conn
+	closed bool
+}
+
+// NewPool creates a Pool which will keep up to size idle connections to addr
+// open for reuse.
+func NewPool(network, addr string, size int) (*Pool, error) {
+	return &Pool{network: network, addr: addr, size: size}, nil
+}
+
+func (p *Pool) get() (Conn, error) {
+	p.l.Lock()
+	if n := len(p.conns); n > 0 {
+		c := p.conns[n-1]
+		p.conns = p.conns[:n-1]
+		p.l.Unlock()
+		return c, nil
+	}
+	p.l.Unlock()
+	return Dial(p.network, p.addr)
+}
+
+func (p *Pool) put(c Conn) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	if p.closed || len(p.conns) >= p.size {
+		c.Close()
+		return
+	}
+	p.conns = append(p.conns, c)
+}
+
+// Do implements the Client interface. The connection a is run on is returned
+// to the Pool on success or on a RESP error reply (e.g. MOVED, WRONGTYPE) -
+// the wire protocol is still in a known-good state either way - but closed
+// on any other error, since the connection can no longer be trusted.
+func (p *Pool) Do(a Action) error {
+	c, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = a.Run(c)
+	if _, ok := err.(errorReply); err == nil || ok {
+		p.put(c)
+	} else {
+		c.Close()
+	}
+	return err
+}
+
+// Close closes every connection currently idle in the Pool.
+func (p *Pool) Close() error {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.closed = true
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+	return nil
+}
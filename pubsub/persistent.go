@@ -7,8 +7,20 @@ import (
 	radix "github.com/mediocregopher/radix.v2"
 )
 
+// PersistentOpts are used to configure the behavior of a Conn returned from
+// NewPersistentWithOpts. A zero-value PersistentOpts gives the same behavior
+// as NewPersistent.
+type PersistentOpts struct {
+	// ClientName, if set, is used to tag the underlying connection via
+	// CLIENT SETNAME, both on the initial dial and after every reconnect.
+	// If "auto", a unique name per connection is generated by
+	// radix.ResolveClientName.
+	ClientName string
+}
+
 type persistent struct {
 	dial func() (radix.Conn, error)
+	opts PersistentOpts
 
 	l           sync.Mutex
 	curr        Conn
@@ -22,8 +34,16 @@ type persistent struct {
 // the returned Conn will ever return an error, they will instead block until a
 // connection can be successfully reinstated.
 func NewPersistent(dialFn func() (radix.Conn, error)) Conn {
+	return NewPersistentWithOpts(dialFn, PersistentOpts{})
+}
+
+// NewPersistentWithOpts is like NewPersistent, but allows for specifying
+// options which affect the Conn's behavior, such as tagging its underlying
+// connection via CLIENT SETNAME.
+func NewPersistentWithOpts(dialFn func() (radix.Conn, error), opts PersistentOpts) Conn {
 	p := &persistent{
 		dial:    dialFn,
+		opts:    opts,
 		subs:    chanSet{},
 		psubs:   chanSet{},
 		closeCh: make(chan struct{}),
@@ -42,6 +62,12 @@ func (p *persistent) refresh() {
 		if err != nil {
 			return nil
 		}
+		if p.opts.ClientName != "" {
+			if err := radix.SetClientName(c, p.opts.ClientName); err != nil {
+				c.Close()
+				return nil
+			}
+		}
 		errCh := make(chan error, 1)
 		pc := newInner(c, errCh)
 
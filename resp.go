@@ -0,0 +1,129 @@
+package radix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// errorReply is an error reply from redis itself (e.g. "MOVED ...", "ASK
+// ...", "WRONGTYPE ..."), as opposed to a connection or decoding error. A
+// Pool keeps a connection after one of these, since the wire protocol is
+// still in a known-good state; it discards the connection on any other
+// error.
+type errorReply string
+
+func (e errorReply) Error() string { return string(e) }
+
+// writeCmd writes cmd and args to w as a RESP array of bulk strings, the
+// wire format redis expects for a command.
+func writeCmd(w io.Writer, cmd string, args []interface{}) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)+1), 10)
+	buf = append(buf, '\r', '\n')
+	buf = appendBulk(buf, []byte(cmd))
+	for _, a := range args {
+		buf = appendBulk(buf, argBytes(a))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendBulk(buf, p []byte) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(p)), 10)
+	buf = append(buf, '\r', '\n')
+	buf = append(buf, p...)
+	return append(buf, '\r', '\n')
+}
+
+// argBytes converts a single command argument into its wire representation.
+func argBytes(a interface{}) []byte {
+	switch v := a.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case uint16:
+		return strconv.AppendUint(nil, uint64(v), 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64)
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case fmt.Stringer:
+		return []byte(v.String())
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// readReply reads a single RESP reply off of br, returning it as one of
+// []byte (bulk/simple string), int64, []interface{} (array), or nil (a
+// null bulk string/array). A RESP error reply is returned as an errorReply
+// error, not as a value.
+func readReply(br *bufio.Reader) (interface{}, error) {
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	} else if len(line) == 0 {
+		return nil, fmt.Errorf("radix: empty reply line")
+	}
+
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, errorReply(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		return n, err
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = readReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("radix: unknown reply type %q", line[0])
+	}
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,257 @@
+// Package ring provides a Client which shards keys across a set of
+// independent (non-clustered) redis instances using rendezvous (HRW)
+// hashing, for users who want simple client-side sharding without running
+// redis cluster.
+package ring
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash"
+	radix "github.com/mediocregopher/radix.v2"
+)
+
+// ErrNoKey is returned by Do when the given Action has no key (Key()
+// returns nil) and so can't be routed to a single shard. Use ForEachShard
+// instead for Actions which need to run against every shard.
+var ErrNoKey = errors.New("ring: action has no key")
+
+// shard is a single member of the Ring.
+type shard struct {
+	name string
+	addr string
+	pool radix.Client
+}
+
+// Ring is a Client which shards keys across a set of independently running
+// redis instances. All methods on Ring are thread-safe.
+type Ring struct {
+	pf radix.PoolFunc
+
+	l      sync.RWMutex
+	shards map[string]*shard // live shards, keyed by name
+	down   map[string]*shard // shards which failed their last health check
+
+	closeCh chan struct{}
+}
+
+// NewRing initializes a Ring from the given shard name to address mapping,
+// connecting a pool to each one via pf. If pf is nil, radix.DefaultPoolFunc
+// is used.
+func NewRing(pf radix.PoolFunc, shards map[string]string) (*Ring, error) {
+	if pf == nil {
+		pf = radix.DefaultPoolFunc
+	}
+	r := &Ring{
+		pf:      pf,
+		shards:  map[string]*shard{},
+		down:    map[string]*shard{},
+		closeCh: make(chan struct{}),
+	}
+
+	for name, addr := range shards {
+		if err := r.AddShard(name, addr); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	go r.healthCheckEvery(5 * time.Second)
+
+	return r, nil
+}
+
+// AddShard adds a new shard to the Ring, connecting a pool to it via the
+// Ring's PoolFunc. If a shard with the same name already exists, whether
+// live or currently marked down by the health-checker, its pool is closed
+// and replaced.
+func (r *Ring) AddShard(name, addr string) error {
+	p, err := r.pf("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	r.l.Lock()
+	old := r.shards[name]
+	oldDown := r.down[name]
+	delete(r.down, name)
+	r.shards[name] = &shard{name: name, addr: addr, pool: p}
+	r.l.Unlock()
+
+	if old != nil {
+		old.pool.Close()
+	}
+	if oldDown != nil {
+		oldDown.pool.Close()
+	}
+	return nil
+}
+
+// RemoveShard removes the named shard from the Ring, whether it's currently
+// live or marked down by the health-checker, and closes its pool. Keys
+// which hashed to that shard will be transparently migrated to one of the
+// remaining shards.
+func (r *Ring) RemoveShard(name string) {
+	r.l.Lock()
+	s, ok := r.shards[name]
+	delete(r.shards, name)
+	down, downOk := r.down[name]
+	delete(r.down, name)
+	r.l.Unlock()
+
+	if ok {
+		s.pool.Close()
+	}
+	if downOk {
+		down.pool.Close()
+	}
+}
+
+// rendezvousHash returns a score for the given shard name and key, used to
+// implement highest-random-weight (rendezvous) hashing: for a given key, the
+// shard with the highest score is chosen. This gives minimal key movement
+// when shards are added or removed, unlike modulo hashing.
+func rendezvousHash(shardName string, key []byte) uint64 {
+	h := xxhash.New()
+	h.Write([]byte(shardName))
+	h.Write([]byte("|"))
+	h.Write(key)
+	return h.Sum64()
+}
+
+// shardForKey returns the live shard which owns the given key, or nil if no
+// shards are live.
+func (r *Ring) shardForKey(key []byte) *shard {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	var best *shard
+	var bestScore uint64
+	for name, s := range r.shards {
+		score := rendezvousHash(name, key)
+		if best == nil || score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	return best
+}
+
+// Do implements the method for the radix.Client interface. The Action is
+// routed to whichever live shard owns its key, as returned by its Key()
+// method. If the Action has no key, ErrNoKey is returned; use ForEachShard
+// instead.
+func (r *Ring) Do(a radix.Action) error {
+	key := a.Key()
+	if key == nil {
+		return ErrNoKey
+	}
+
+	s := r.shardForKey(key)
+	if s == nil {
+		return errors.New("ring: no live shards")
+	}
+	return s.pool.Do(a)
+}
+
+// ForEachShard calls fn, in parallel, with the pool for every currently live
+// shard. It's meant for Actions with no key, or for maintenance commands
+// which need to run against every shard. The first error encountered, if
+// any, is returned.
+func (r *Ring) ForEachShard(fn func(addr string, p radix.Client) error) error {
+	r.l.RLock()
+	shards := make([]*shard, 0, len(r.shards))
+	for _, s := range r.shards {
+		shards = append(shards, s)
+	}
+	r.l.RUnlock()
+
+	errCh := make(chan error, len(shards))
+	for _, s := range shards {
+		go func(s *shard) {
+			errCh <- fn(s.addr, s.pool)
+		}(s)
+	}
+
+	var firstErr error
+	for range shards {
+		if err := <-errCh; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// healthCheckEvery periodically PINGs every shard's pool and removes any
+// which fail to respond, re-adding them once they start responding again.
+// It's meant to be run in its own go-routine for the lifetime of the Ring.
+func (r *Ring) healthCheckEvery(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			r.healthCheck()
+		case <-r.closeCh:
+			return
+		}
+	}
+}
+
+// healthCheck PINGs every currently live shard, moving any which fail to
+// respond into r.down, and PINGs every currently down shard, moving any
+// which respond back into r.shards.
+func (r *Ring) healthCheck() {
+	r.l.RLock()
+	live := make([]*shard, 0, len(r.shards))
+	for _, s := range r.shards {
+		live = append(live, s)
+	}
+	down := make([]*shard, 0, len(r.down))
+	for _, s := range r.down {
+		down = append(down, s)
+	}
+	r.l.RUnlock()
+
+	for _, s := range live {
+		if err := s.pool.Do(radix.CmdNoKey("PING")); err != nil {
+			r.l.Lock()
+			// only move it to down if AddShard/RemoveShard hasn't already
+			// touched this name out from under us since live was built
+			if r.shards[s.name] == s {
+				delete(r.shards, s.name)
+				r.down[s.name] = s
+			}
+			r.l.Unlock()
+		}
+	}
+
+	for _, s := range down {
+		if err := s.pool.Do(radix.CmdNoKey("PING")); err == nil {
+			r.l.Lock()
+			if r.down[s.name] == s {
+				delete(r.down, s.name)
+				r.shards[s.name] = s
+			}
+			r.l.Unlock()
+		}
+	}
+}
+
+// Close closes the pools of all shards, live or currently marked down, and
+// stops the health-checker go-routine.
+func (r *Ring) Close() error {
+	close(r.closeCh)
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	for _, s := range r.shards {
+		s.pool.Close()
+	}
+	for _, s := range r.down {
+		s.pool.Close()
+	}
+	return nil
+}
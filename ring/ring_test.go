@@ -0,0 +1,99 @@
+package ring
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	radix "github.com/mediocregopher/radix.v2"
+)
+
+// fakeClient is a radix.Client whose Do either always succeeds or always
+// fails, and which records whether it's been closed.
+type fakeClient struct {
+	fail   bool
+	closed bool
+}
+
+func (f *fakeClient) Do(a radix.Action) error {
+	if f.fail {
+		return assert.AnError
+	}
+	return nil
+}
+
+func (f *fakeClient) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newRing() *Ring {
+	return &Ring{shards: map[string]*shard{}, down: map[string]*shard{}}
+}
+
+func TestRendezvousHashDeterministic(t *T) {
+	a := rendezvousHash("shard-a", []byte("foo"))
+	b := rendezvousHash("shard-a", []byte("foo"))
+	assert.Equal(t, a, b)
+
+	c := rendezvousHash("shard-b", []byte("foo"))
+	assert.NotEqual(t, a, c)
+}
+
+func TestHealthCheckMarksDown(t *T) {
+	r := newRing()
+	fc := &fakeClient{fail: true}
+	r.shards["a"] = &shard{name: "a", addr: "addr-a", pool: fc}
+
+	r.healthCheck()
+
+	_, liveOk := r.shards["a"]
+	down, downOk := r.down["a"]
+	assert.False(t, liveOk)
+	require.True(t, downOk)
+	assert.Same(t, fc, down.pool)
+}
+
+func TestHealthCheckResurrectsDown(t *T) {
+	r := newRing()
+	fc := &fakeClient{fail: false}
+	r.down["a"] = &shard{name: "a", addr: "addr-a", pool: fc}
+
+	r.healthCheck()
+
+	_, downOk := r.down["a"]
+	live, liveOk := r.shards["a"]
+	assert.False(t, downOk)
+	require.True(t, liveOk)
+	assert.Same(t, fc, live.pool)
+}
+
+func TestRemoveShardClosesDownShard(t *T) {
+	r := newRing()
+	fc := &fakeClient{fail: true}
+	r.down["a"] = &shard{name: "a", addr: "addr-a", pool: fc}
+
+	r.RemoveShard("a")
+
+	_, ok := r.down["a"]
+	assert.False(t, ok)
+	assert.True(t, fc.closed)
+}
+
+func TestAddShardReplacesDownShard(t *T) {
+	r := newRing()
+	oldFc := &fakeClient{fail: true}
+	r.down["a"] = &shard{name: "a", addr: "old-addr", pool: oldFc}
+	r.pf = func(network, addr string) (radix.Client, error) {
+		return &fakeClient{}, nil
+	}
+
+	require.Nil(t, r.AddShard("a", "new-addr"))
+
+	_, downOk := r.down["a"]
+	assert.False(t, downOk)
+	assert.True(t, oldFc.closed)
+	require.Contains(t, r.shards, "a")
+	assert.Equal(t, "new-addr", r.shards["a"].addr)
+}
@@ -0,0 +1,348 @@
+// Package sentinel provides a Client which uses Redis Sentinel to discover
+// and maintain a connection to the current master of a given set of Redis
+// instances, providing automatic failover without requiring Redis Cluster.
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	radix "github.com/mediocregopher/radix.v2"
+	"github.com/mediocregopher/radix.v2/pubsub"
+)
+
+// SentinelOpts are used to configure the behavior of a Sentinel.
+type SentinelOpts struct {
+	// ClientName, if set, is used to tag every connection Sentinel makes
+	// (to the master, to any replica, and to the sentinels themselves) via
+	// CLIENT SETNAME. If "auto", a unique name is generated by
+	// radix.ResolveClientName: one per pool for the master and replica
+	// pools, and one per connection for connections to the sentinels.
+	ClientName string
+}
+
+// Sentinel is a Client which, rather than connecting directly to a Redis
+// instance, connects to a set of Sentinel instances and uses them to
+// discover and connect to the current master for a given master name. If the
+// master fails over to a replica, Sentinel will be notified via pubsub and
+// will transparently reconnect to the new master. All methods on Sentinel
+// are thread-safe.
+type Sentinel struct {
+	pf         radix.PoolFunc
+	opts       SentinelOpts
+	masterName string
+
+	// the pubsub connection to whichever sentinel instance is currently in
+	// use. It's kept persistent so it keeps receiving +switch-master events
+	// across reconnects. The periodic refresh of the known sentinel and
+	// replica addresses dials its own, separate connections.
+	pubsub pubsub.Conn
+
+	l             sync.RWMutex
+	pool          radix.Client // pool to the current master
+	slavePool     radix.Client // pool to a replica, if any are known
+	sentinelAddrs []string
+
+	closeCh chan struct{}
+}
+
+// NewSentinel creates a Sentinel which uses the given sentinelAddrs to
+// discover the master named masterName. It will try every address until it
+// finds one which is reachable, use that to determine the address of the
+// current master, and use pf to create a pool to that master.
+//
+// The Sentinel periodically refreshes its set of sentinels and replicas for
+// masterName, and reconnects to the master whenever a +switch-master event is
+// received.
+func NewSentinel(pf radix.PoolFunc, masterName string, sentinelAddrs ...string) (*Sentinel, error) {
+	return NewSentinelWithOpts(pf, masterName, sentinelAddrs, SentinelOpts{})
+}
+
+// NewSentinelWithOpts is like NewSentinel, but allows for specifying options
+// which affect the Sentinel's behavior, such as tagging its connections via
+// CLIENT SETNAME.
+func NewSentinelWithOpts(pf radix.PoolFunc, masterName string, sentinelAddrs []string, opts SentinelOpts) (*Sentinel, error) {
+	if pf == nil {
+		pf = radix.DefaultPoolFunc
+	}
+	s := &Sentinel{
+		pf:            pf,
+		opts:          opts,
+		masterName:    masterName,
+		sentinelAddrs: sentinelAddrs,
+		closeCh:       make(chan struct{}),
+	}
+
+	s.pubsub = pubsub.NewPersistentWithOpts(s.dialAnySentinel, pubsub.PersistentOpts{
+		ClientName: opts.ClientName,
+	})
+
+	addr, err := s.getMasterAddr()
+	if err != nil {
+		s.pubsub.Close()
+		return nil, err
+	}
+	if err := s.setMaster(addr); err != nil {
+		s.pubsub.Close()
+		return nil, err
+	}
+
+	msgCh := make(chan pubsub.Message)
+	if err := s.pubsub.Subscribe(msgCh, "+switch-master"); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	go s.spin(msgCh)
+	go s.syncEvery(30 * time.Second)
+
+	return s, nil
+}
+
+// dialSentinel dials each of the known sentinel addresses in turn until one
+// responds, and returns a Conn to it along with the address that was
+// actually used. It's used for the initial discovery, by syncSlaves, and by
+// syncSentinels, which needs to know which address answered.
+func (s *Sentinel) dialSentinel() (radix.Conn, string, error) {
+	s.l.RLock()
+	addrs := s.sentinelAddrs
+	s.l.RUnlock()
+
+	var lastErr error
+	for _, addr := range addrs {
+		c, err := radix.Dial("tcp", addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if s.opts.ClientName != "" {
+			radix.SetClientName(c, s.opts.ClientName)
+		}
+		return c, addr, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("sentinel: no sentinel addresses given")
+	}
+	return nil, "", lastErr
+}
+
+// dialAnySentinel is like dialSentinel, but discards which address was
+// used. It's used by the persistent pubsub connection, which only cares
+// about reconnecting, not about updating sentinelAddrs.
+func (s *Sentinel) dialAnySentinel() (radix.Conn, error) {
+	c, _, err := s.dialSentinel()
+	return c, err
+}
+
+// getMasterAddr asks the sentinels for the address of the current master.
+func (s *Sentinel) getMasterAddr() (string, error) {
+	c, _, err := s.dialSentinel()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	var addr []string
+	cmd := radix.CmdNoKey("SENTINEL", "get-master-addr-by-name", s.masterName).Into(&addr)
+	if err := cmd.Run(c); err != nil {
+		return "", err
+	} else if len(addr) != 2 {
+		return "", fmt.Errorf("sentinel: malformed get-master-addr-by-name response: %v", addr)
+	}
+	return addr[0] + ":" + addr[1], nil
+}
+
+// setMaster creates a new pool to addr and swaps it in as the current
+// master, closing the old pool. This is done even if addr is the same as the
+// current master's address.
+func (s *Sentinel) setMaster(addr string) error {
+	p, err := s.pf("tcp", addr)
+	if err != nil {
+		return err
+	}
+	p = radix.TagConnNames(p, s.opts.ClientName)
+
+	s.l.Lock()
+	old := s.pool
+	s.pool = p
+	s.l.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// spin reads +switch-master notifications off of msgCh and reconnects to the
+// new master whenever one comes in. It's meant to be run in its own
+// go-routine.
+func (s *Sentinel) spin(msgCh chan pubsub.Message) {
+	for {
+		select {
+		case <-msgCh:
+			addr, err := s.getMasterAddr()
+			if err != nil {
+				continue
+			}
+			s.setMaster(addr)
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// syncEvery periodically refreshes the known sentinel and replica addresses.
+// It's meant to be run in its own go-routine.
+func (s *Sentinel) syncEvery(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.syncSentinels()
+			s.syncSlaves()
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// syncSentinels re-queries SENTINEL sentinels and updates the set of known
+// sentinel addresses used for future reconnects.
+func (s *Sentinel) syncSentinels() {
+	c, respondingAddr, err := s.dialSentinel()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	var res [][]string
+	cmd := radix.CmdNoKey("SENTINEL", "sentinels", s.masterName).Into(&res)
+	if err := cmd.Run(c); err != nil {
+		return
+	}
+
+	// SENTINEL sentinels never includes the instance being queried in its
+	// own response, so respondingAddr has to be added back in explicitly -
+	// it's not necessarily sentinelAddrs[0], since dialSentinel may have
+	// skipped over unreachable addresses to find it.
+	addrs := []string{respondingAddr}
+	for _, kv := range res {
+		if addr := addrForKVs(kv); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	s.l.Lock()
+	s.sentinelAddrs = addrs
+	s.l.Unlock()
+}
+
+// syncSlaves re-queries SENTINEL slaves and, if any are found, creates or
+// updates the read-only pool returned by SlavePool.
+func (s *Sentinel) syncSlaves() {
+	c, _, err := s.dialSentinel()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	var res [][]string
+	cmd := radix.CmdNoKey("SENTINEL", "slaves", s.masterName).Into(&res)
+	if err := cmd.Run(c); err != nil || len(res) == 0 {
+		return
+	}
+
+	addr := addrForKVs(res[0])
+	if addr == "" {
+		return
+	}
+
+	p, err := s.pf("tcp", addr)
+	if err != nil {
+		return
+	}
+	p = radix.TagConnNames(p, s.opts.ClientName)
+
+	s.l.Lock()
+	old := s.slavePool
+	s.slavePool = p
+	s.l.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+}
+
+// addrForKVs pulls the ip/port fields out of a flattened field/value list, as
+// returned by SENTINEL sentinels/slaves.
+func addrForKVs(kv []string) string {
+	var ip, port string
+	for i := 0; i+1 < len(kv); i += 2 {
+		switch kv[i] {
+		case "ip":
+			ip = kv[i+1]
+		case "port":
+			port = kv[i+1]
+		}
+	}
+	if ip == "" || port == "" {
+		return ""
+	}
+	return ip + ":" + port
+}
+
+// Do implements the method for the radix.Client interface, routing the
+// Action to the current master.
+func (s *Sentinel) Do(a radix.Action) error {
+	return s.DoCtx(context.Background(), a)
+}
+
+// DoCtx is like Do, but will abort early with ctx.Err() if ctx is canceled
+// or expires before the Action runs. If a implements radix.ActionCtx, its
+// RunCtx method is used instead of Run, via radix.WithConn, so the context
+// can also be honored by the underlying connection.
+func (s *Sentinel) DoCtx(ctx context.Context, a radix.Action) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.l.RLock()
+	p := s.pool
+	s.l.RUnlock()
+
+	if ca, ok := a.(radix.ActionCtx); ok {
+		return p.Do(radix.WithConn(a.Key(), func(conn radix.Conn) error {
+			return ca.RunCtx(ctx, conn)
+		}))
+	}
+	return p.Do(a)
+}
+
+// SlavePool returns a Client which can be used for read-only commands
+// against a replica of the master, or nil if no replica is currently known.
+func (s *Sentinel) SlavePool() radix.Client {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.slavePool
+}
+
+// Close cleans up all go-routines spawned by Sentinel and closes all of its
+// connections and pools.
+func (s *Sentinel) Close() error {
+	close(s.closeCh)
+	s.pubsub.Close()
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.pool != nil {
+		s.pool.Close()
+	}
+	if s.slavePool != nil {
+		s.slavePool.Close()
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package sentinel
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddrForKVs(t *T) {
+	kv := []string{"name", "sentinel-1", "ip", "10.0.0.1", "port", "26379", "flags", "sentinel"}
+	assert.Equal(t, "10.0.0.1:26379", addrForKVs(kv))
+
+	assert.Equal(t, "", addrForKVs([]string{"name", "sentinel-1"}))
+}